@@ -0,0 +1,310 @@
+// Copyright 2021 Kevin Retzke
+//
+// This program is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License for more
+// details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SchedulerMode selects how a ClientPool orders its endpoints for a given
+// call.
+type SchedulerMode int
+
+const (
+	// RoundRobin rotates the starting endpoint on every call.
+	RoundRobin SchedulerMode = iota
+	// FirstHealthy always prefers the first configured endpoint, falling
+	// back to the others only once it's marked unhealthy.
+	FirstHealthy
+	// AllFanout is reserved for calls that should reach every endpoint;
+	// queryAPI only needs a single response, so it is currently treated
+	// the same as FirstHealthy.
+	AllFanout
+)
+
+// ParseSchedulerMode maps a yaml "scheduler" value to a SchedulerMode,
+// defaulting to RoundRobin.
+func ParseSchedulerMode(s string) SchedulerMode {
+	switch s {
+	case "first-healthy":
+		return FirstHealthy
+	case "all-fanout":
+		return AllFanout
+	default:
+		return RoundRobin
+	}
+}
+
+const (
+	initialBackoff = 5 * time.Second
+	maxBackoff     = 5 * time.Minute
+	unhealthyAfter = 3
+)
+
+// poolEndpoint is a single upstream RPC target tracked by a ClientPool.
+type poolEndpoint struct {
+	url string
+
+	mu         sync.Mutex
+	healthy    bool
+	failures   int
+	backoff    time.Duration
+	retryAfter time.Time
+}
+
+// available reports whether this endpoint should be attempted right now:
+// either it's healthy, or its backoff window has elapsed and it deserves
+// a retry.
+func (e *poolEndpoint) available(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.healthy || !now.Before(e.retryAfter)
+}
+
+func (e *poolEndpoint) markHealthy() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.healthy = true
+	e.failures = 0
+	e.backoff = 0
+}
+
+func (e *poolEndpoint) markUnhealthy(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures++
+	if e.failures >= unhealthyAfter {
+		e.healthy = false
+	}
+	if e.backoff == 0 {
+		e.backoff = initialBackoff
+	} else if e.backoff < maxBackoff {
+		e.backoff *= 2
+		if e.backoff > maxBackoff {
+			e.backoff = maxBackoff
+		}
+	}
+	e.retryAfter = now.Add(e.backoff)
+}
+
+// poolMetrics holds the metric vectors shared by every ClientPool for a
+// single coin. A coin registers one poolMetrics, not one per pool, so its
+// four pools (full-node/wallet/farmer/harvester) don't each try to register
+// their own copy of the same metric name.
+type poolMetrics struct {
+	mu    sync.Mutex
+	pools []*ClientPool
+
+	up        *prometheus.GaugeVec
+	duration  *prometheus.HistogramVec
+	rpcErrors *prometheus.CounterVec
+	rpcDur    *prometheus.HistogramVec
+}
+
+// newPoolMetrics builds the shared vectors for one coin. Pass the result to
+// every NewClientPool call for that coin.
+func newPoolMetrics(coin string) *poolMetrics {
+	return &poolMetrics{
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName(coin, "endpoint_up"),
+			Help: "Whether the pool currently considers this endpoint healthy.",
+		}, []string{"role", "url"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: metricName(coin, "endpoint_request_duration_seconds"),
+			Help: "RPC call duration against a single endpoint.",
+		}, []string{"role", "url"}),
+		rpcErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: metricName(coin, "rpc_errors_total"),
+			Help: "RPC call failures, by endpoint and failure reason.",
+		}, []string{"endpoint", "reason"}),
+		rpcDur: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: metricName(coin, "rpc_duration_seconds"),
+			Help: "RPC call duration, by endpoint, regardless of which pool member served it.",
+		}, []string{"endpoint"}),
+	}
+}
+
+// register tracks p so Collect can refresh its endpoints' "up" gauge.
+func (m *poolMetrics) register(p *ClientPool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pools = append(m.pools, p)
+}
+
+func (m *poolMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.up.Describe(ch)
+	m.duration.Describe(ch)
+	m.rpcErrors.Describe(ch)
+	m.rpcDur.Describe(ch)
+}
+
+func (m *poolMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.mu.Lock()
+	pools := append([]*ClientPool(nil), m.pools...)
+	m.mu.Unlock()
+
+	now := time.Now()
+	for _, p := range pools {
+		for _, ep := range p.endpoints {
+			up := 0.0
+			if ep.available(now) {
+				up = 1.0
+			}
+			m.up.WithLabelValues(p.role, ep.url).Set(up)
+		}
+	}
+	m.up.Collect(ch)
+	m.duration.Collect(ch)
+	m.rpcErrors.Collect(ch)
+	m.rpcDur.Collect(ch)
+}
+
+// ClientPool dispatches RPC calls across one or more endpoints for a single
+// role (full-node, wallet, farmer or harvester) of a single coin, so an
+// operator can run more than one daemon behind one exporter and keep
+// scraping correctly when one of them restarts.
+type ClientPool struct {
+	coin     string
+	role     string
+	client   *http.Client
+	mode     SchedulerMode
+	timeouts map[string]time.Duration
+	metrics  *poolMetrics
+
+	mu        sync.Mutex
+	endpoints []*poolEndpoint
+	next      int
+
+	// scrapeMu is held for the full duration of one scrape (from
+	// beginScrape through the matching endScrape), so two concurrent
+	// /metrics requests against the same pool can never race on ctx or
+	// have one cancel the other's in-flight calls; see scrapeHandler in
+	// main.go.
+	scrapeMu sync.Mutex
+	ctx      context.Context
+}
+
+// NewClientPool builds a pool for one role of one coin. metrics is shared
+// across every role of that coin (see newPoolMetrics) so the resulting
+// endpoint_up/endpoint_request_duration_seconds/rpc_* series all land in
+// one set of vectors instead of colliding on registration.
+func NewClientPool(coin, role string, client *http.Client, urls []string, mode SchedulerMode, timeouts map[string]time.Duration, metrics *poolMetrics) *ClientPool {
+	endpoints := make([]*poolEndpoint, len(urls))
+	for i, u := range urls {
+		endpoints[i] = &poolEndpoint{url: u, healthy: true}
+	}
+	p := &ClientPool{
+		coin:      coin,
+		role:      role,
+		client:    client,
+		mode:      mode,
+		timeouts:  timeouts,
+		metrics:   metrics,
+		endpoints: endpoints,
+		ctx:       context.Background(),
+	}
+	metrics.register(p)
+	return p
+}
+
+// beginScrape locks the pool to a single scrape and sets the context
+// queryAPI will use until the matching endScrape, so a slow RPC can't hold
+// a scrape open past when Prometheus has already given up. A second
+// concurrent scrape of this pool blocks here until the first calls
+// endScrape, rather than racing on or cancelling the first scrape's
+// context.
+func (p *ClientPool) beginScrape(ctx context.Context) {
+	p.scrapeMu.Lock()
+	p.ctx = ctx
+}
+
+// endScrape releases the pool for the next scrape.
+func (p *ClientPool) endScrape() {
+	p.ctx = context.Background()
+	p.scrapeMu.Unlock()
+}
+
+func (p *ClientPool) context() context.Context {
+	return p.ctx
+}
+
+// order returns the endpoints to try, in the order they should be tried,
+// according to the pool's scheduler mode.
+func (p *ClientPool) order() []*poolEndpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ordered := make([]*poolEndpoint, len(p.endpoints))
+	switch p.mode {
+	case RoundRobin:
+		for i := range p.endpoints {
+			ordered[i] = p.endpoints[(p.next+i)%len(p.endpoints)]
+		}
+		p.next = (p.next + 1) % len(p.endpoints)
+	default: // FirstHealthy, AllFanout
+		copy(ordered, p.endpoints)
+	}
+	return ordered
+}
+
+// queryAPI tries queryAPI against endpoints in scheduler order, skipping
+// ones known to be unhealthy, until one succeeds. Calls are bounded by the
+// pool's current scrape context, overridden per-RPC-endpoint by the
+// "timeouts" yaml setting when present.
+func (p *ClientPool) queryAPI(endpoint, query string, result interface{}) error {
+	ctx := p.context()
+	if d, ok := p.timeouts[endpoint]; ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	now := time.Now()
+	var lastErr error
+	tried := 0
+	for _, ep := range p.order() {
+		if !ep.available(now) {
+			continue
+		}
+		tried++
+		start := time.Now()
+		err := queryAPI(ctx, p.client, ep.url, endpoint, query, result)
+		took := time.Since(start).Seconds()
+		p.metrics.duration.WithLabelValues(p.role, ep.url).Observe(took)
+		p.metrics.rpcDur.WithLabelValues(endpoint).Observe(took)
+		if err != nil {
+			lastErr = err
+			reason := "error"
+			if ctx.Err() == context.DeadlineExceeded {
+				reason = "timeout"
+			}
+			p.metrics.rpcErrors.WithLabelValues(endpoint, reason).Inc()
+			ep.markUnhealthy(time.Now())
+			continue
+		}
+		ep.markHealthy()
+		return nil
+	}
+	if tried == 0 {
+		return fmt.Errorf("%s pool: no healthy endpoints for %s", p.role, endpoint)
+	}
+	return fmt.Errorf("%s pool: all endpoints failed for %s: %w", p.role, endpoint, lastErr)
+}
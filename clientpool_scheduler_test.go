@@ -0,0 +1,123 @@
+// Copyright 2021 Kevin Retzke
+//
+// This program is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License for more
+// details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func endpointURLs(eps []*poolEndpoint) []string {
+	urls := make([]string, len(eps))
+	for i, e := range eps {
+		urls[i] = e.url
+	}
+	return urls
+}
+
+func TestClientPoolOrderRoundRobin(t *testing.T) {
+	p := NewClientPool("chia", "full-node", nil, []string{"a", "b", "c"}, RoundRobin, nil, newPoolMetrics("chia"))
+
+	if got, want := endpointURLs(p.order()), []string{"a", "b", "c"}; !equalStrings(got, want) {
+		t.Errorf("1st call order = %v, want %v", got, want)
+	}
+	if got, want := endpointURLs(p.order()), []string{"b", "c", "a"}; !equalStrings(got, want) {
+		t.Errorf("2nd call order = %v, want %v", got, want)
+	}
+	if got, want := endpointURLs(p.order()), []string{"c", "a", "b"}; !equalStrings(got, want) {
+		t.Errorf("3rd call order = %v, want %v", got, want)
+	}
+}
+
+func TestClientPoolOrderFirstHealthy(t *testing.T) {
+	p := NewClientPool("chia", "full-node", nil, []string{"a", "b", "c"}, FirstHealthy, nil, newPoolMetrics("chia"))
+
+	for i := 0; i < 3; i++ {
+		if got, want := endpointURLs(p.order()), []string{"a", "b", "c"}; !equalStrings(got, want) {
+			t.Errorf("call %d order = %v, want %v (always starts at the first endpoint)", i, got, want)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPoolEndpointBackoff(t *testing.T) {
+	e := &poolEndpoint{url: "a", healthy: true}
+	now := time.Now()
+
+	// Fewer than unhealthyAfter failures keep the endpoint healthy.
+	for i := 0; i < unhealthyAfter-1; i++ {
+		e.markUnhealthy(now)
+		if !e.available(now) {
+			t.Fatalf("endpoint marked unavailable after only %d failure(s), want healthy until %d", i+1, unhealthyAfter)
+		}
+	}
+
+	// The unhealthyAfter-th failure flips it unhealthy and starts the
+	// backoff window.
+	e.markUnhealthy(now)
+	if e.available(now) {
+		t.Fatal("endpoint still available immediately after crossing unhealthyAfter failures")
+	}
+	firstBackoff := e.backoff
+	if e.available(now.Add(firstBackoff - time.Second)) {
+		t.Fatal("endpoint available before its backoff window elapsed")
+	}
+	if !e.available(now.Add(firstBackoff)) {
+		t.Fatal("endpoint should be available once its backoff window elapses")
+	}
+
+	// A further failure doubles the backoff rather than resetting it.
+	e.markUnhealthy(now)
+	if got, want := e.backoff, 2*firstBackoff; got != want {
+		t.Fatalf("backoff after another failure = %v, want %v (doubled)", got, want)
+	}
+	if e.available(now.Add(firstBackoff)) {
+		t.Fatal("endpoint available before its doubled backoff window elapsed")
+	}
+	if !e.available(now.Add(2 * firstBackoff)) {
+		t.Fatal("endpoint should be available once the doubled backoff window elapses")
+	}
+
+	e.markHealthy()
+	if !e.available(now) {
+		t.Fatal("endpoint should be immediately available after markHealthy")
+	}
+	if e.backoff != 0 {
+		t.Fatalf("backoff after markHealthy = %v, want 0", e.backoff)
+	}
+}
+
+func TestPoolEndpointBackoffCapsAtMax(t *testing.T) {
+	e := &poolEndpoint{url: "a", healthy: true}
+	now := time.Now()
+
+	for i := 0; i < 30; i++ {
+		e.markUnhealthy(now)
+	}
+	if e.backoff != maxBackoff {
+		t.Fatalf("backoff after many failures = %v, want it capped at maxBackoff %v", e.backoff, maxBackoff)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2021 Kevin Retzke
+//
+// This program is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License for more
+// details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestClientPoolScrapeSerializes verifies that a second scrape can't observe
+// or replace the context of a scrape still in progress: it must block in
+// beginScrape until the first scrape calls endScrape.
+func TestClientPoolScrapeSerializes(t *testing.T) {
+	p := NewClientPool("chia", "full-node", nil, []string{"a"}, RoundRobin, nil, newPoolMetrics("chia"))
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	defer cancelA()
+	p.beginScrape(ctxA)
+
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		close(started)
+		p.beginScrape(context.Background())
+		defer p.endScrape()
+		close(done)
+	}()
+	<-started
+
+	select {
+	case <-done:
+		t.Fatal("second scrape's beginScrape returned before the first scrape ended")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if got := p.context(); got != ctxA {
+		t.Fatal("context changed while the first scrape still held the pool")
+	}
+
+	p.endScrape()
+	<-done
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 Kevin Retzke
+//
+// This program is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License for more
+// details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// captureTargets lists the RPC endpoints recorded by "capture" for each pool
+// role. Endpoints that take extra request parameters (the per-wallet RPCs)
+// aren't included; add fixtures for those by hand.
+var captureTargets = map[string][]string{
+	"full-node": {"get_network_info", "get_connections", "get_blockchain_state"},
+	"wallet":    {"get_wallets"},
+	"farmer":    {"get_pool_state"},
+	"harvester": {"get_plots"},
+}
+
+// runCapture implements "chia_exporter_nforks capture -coin <name> -out <dir>":
+// it records live RPC responses for a configured coin into a
+// testdata/vectors-style directory, making adding fixtures for a new fork a
+// one-command operation.
+func runCapture(args []string) error {
+	fs := flag.NewFlagSet("capture", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Config file dir")
+	coinName := fs.String("coin", "", "Coin name as configured in config.yaml")
+	out := fs.String("out", "", "Directory to write <endpoint>.json fixtures to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *coinName == "" || *out == "" {
+		return fmt.Errorf("capture requires -coin and -out")
+	}
+
+	cfgSrc, err := ioutil.ReadFile(*configPath)
+	if err != nil {
+		return err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(cfgSrc, &cfg); err != nil {
+		return err
+	}
+	coin, ok := cfg.Coins[*coinName]
+	if !ok {
+		return fmt.Errorf("coin %q not found in %s", *coinName, *configPath)
+	}
+
+	client, err := newClient(os.ExpandEnv(coin.Cert), os.ExpandEnv(coin.Key))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		return err
+	}
+
+	roleURLs := map[string][]string{}
+	for role, ports := range map[string]Endpoints{
+		"full-node": coin.FullNodePort,
+		"wallet":    coin.WalletPort,
+		"farmer":    coin.FarmerPort,
+		"harvester": coin.HarvesterPort,
+	} {
+		endpoints, err := urls(coin.Host, ports)
+		if err != nil {
+			return fmt.Errorf("%s endpoints: %w", role, err)
+		}
+		roleURLs[role] = endpoints
+	}
+	for role, endpoints := range captureTargets {
+		base := roleURLs[role][0]
+		for _, endpoint := range endpoints {
+			var raw json.RawMessage
+			if err := queryAPI(context.Background(), client, base, endpoint, "", &raw); err != nil {
+				return fmt.Errorf("capturing %s: %w", endpoint, err)
+			}
+			dst := filepath.Join(*out, endpoint+".json")
+			if err := ioutil.WriteFile(dst, raw, 0o644); err != nil {
+				return err
+			}
+			fmt.Println("wrote", dst)
+		}
+	}
+	return nil
+}
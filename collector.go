@@ -0,0 +1,67 @@
+// Copyright 2021 Kevin Retzke
+//
+// This program is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License for more
+// details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+package main
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	IsConn          = "conn"
+	IsState         = "state"
+	IsWalletSync    = "wallet-sync"
+	IsWalletBalance = "wallet-balance"
+	IsFarmed        = "farmed-amount"
+	IsPlots         = "plots"
+	IsPool          = "pool"
+)
+
+// registerCoin builds the per-subsystem collectors for a single coin and
+// registers them with reg, which callers wrap with the coin's label via
+// prometheus.WrapRegistererWith so every series for that coin can be
+// unregistered together. The pools' shared poolMetrics is registered once,
+// not once per pool, since all four pools for a coin write into the same
+// vectors.
+func registerCoin(reg prometheus.Registerer, coll Collector) {
+	reg.MustRegister(
+		coll.poolMetrics,
+		NewConnectionsCollector(coll.name, coll.fullNode, coll.pullSwitcher),
+		NewChainStateCollector(coll.name, coll.fullNode, coll.pullSwitcher),
+		NewWalletCollector(coll.name, coll.wallet, coll.pullSwitcher),
+		NewPoolCollector(coll.name, coll.farmer, coll.pullSwitcher),
+		NewHarvesterCollector(coll.name, coll.harvester, coll.pullSwitcher),
+	)
+}
+
+// Collector holds everything needed to reach a single coin's daemons. It is
+// a plain data holder; the actual prometheus.Collector implementations live
+// one per subsystem (see collector_*.go) and are constructed from it.
+type Collector struct {
+	name         string
+	fullNode     *ClientPool
+	wallet       *ClientPool
+	farmer       *ClientPool
+	harvester    *ClientPool
+	poolMetrics  *poolMetrics
+	pullSwitcher map[string]bool
+}
+
+// metricName returns the conventional "<coin>_<suffix>" metric name used
+// throughout this exporter.
+func metricName(coin, suffix string) string {
+	return fmt.Sprintf("%s_%s", coin, suffix)
+}
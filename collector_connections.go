@@ -0,0 +1,90 @@
+// Copyright 2021 Kevin Retzke
+//
+// This program is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License for more
+// details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+package main
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ConnectionsCollector reports peer counts and traffic volume from
+// get_connections.
+type ConnectionsCollector struct {
+	name         string
+	pool         *ClientPool
+	pullSwitcher map[string]bool
+
+	peersDesc        *prometheus.Desc
+	bytesReadDesc    *prometheus.Desc
+	bytesWrittenDesc *prometheus.Desc
+}
+
+func NewConnectionsCollector(name string, pool *ClientPool, pullSwitcher map[string]bool) *ConnectionsCollector {
+	return &ConnectionsCollector{
+		name:         name,
+		pool:         pool,
+		pullSwitcher: pullSwitcher,
+
+		peersDesc: prometheus.NewDesc(
+			metricName(name, "peers_count"),
+			"Number of peers currently connected.",
+			[]string{"type"}, nil,
+		),
+		bytesReadDesc: prometheus.NewDesc(
+			metricName(name, "connection_bytes_read_total"),
+			"Total bytes read, summed across connections of a given type.",
+			[]string{"type"}, nil,
+		),
+		bytesWrittenDesc: prometheus.NewDesc(
+			metricName(name, "connection_bytes_written_total"),
+			"Total bytes written, summed across connections of a given type.",
+			[]string{"type"}, nil,
+		),
+	}
+}
+
+func (c *ConnectionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *ConnectionsCollector) Collect(ch chan<- prometheus.Metric) {
+	if !c.pullSwitcher[IsConn] {
+		return
+	}
+
+	var conns Connections
+	if err := c.pool.queryAPI("get_connections", "", &conns); err != nil {
+		log.Print(err)
+		return
+	}
+
+	peers := make([]int, NumNodeTypes)
+	bytesRead := make([]int64, NumNodeTypes)
+	bytesWritten := make([]int64, NumNodeTypes)
+	for _, conn := range conns.Connections {
+		peers[conn.Type-1]++
+		bytesRead[conn.Type-1] += conn.BytesRead
+		bytesWritten[conn.Type-1] += conn.BytesWritten
+	}
+
+	for nt := range peers {
+		typeLabel := strconv.Itoa(nt + 1)
+		ch <- prometheus.MustNewConstMetric(c.peersDesc, prometheus.GaugeValue, float64(peers[nt]), typeLabel)
+		ch <- prometheus.MustNewConstMetric(c.bytesReadDesc, prometheus.CounterValue, float64(bytesRead[nt]), typeLabel)
+		ch <- prometheus.MustNewConstMetric(c.bytesWrittenDesc, prometheus.CounterValue, float64(bytesWritten[nt]), typeLabel)
+	}
+}
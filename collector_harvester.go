@@ -0,0 +1,101 @@
+// Copyright 2021 Kevin Retzke
+//
+// This program is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License for more
+// details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HarvesterCollector reports plot counts from get_plots, along with
+// histograms of plot file size and age so operators can see the
+// distribution of their farm rather than just its total count.
+type HarvesterCollector struct {
+	name         string
+	pool         *ClientPool
+	pullSwitcher map[string]bool
+
+	failedToOpenDesc *prometheus.Desc
+	notFoundDesc     *prometheus.Desc
+	plotsDesc        *prometheus.Desc
+	plotSizeDesc     *prometheus.Desc
+	plotAgeDesc      *prometheus.Desc
+}
+
+// plotSizeBuckets covers k25 (~4.3GiB) test plots through k34 (~557GiB)
+// mainnet plots.
+var plotSizeBuckets = prometheus.ExponentialBuckets(4e9, 1.5, 14)
+
+// plotAgeBuckets spans one hour to roughly two years, in seconds.
+var plotAgeBuckets = prometheus.ExponentialBuckets(3600, 2, 16)
+
+func NewHarvesterCollector(name string, pool *ClientPool, pullSwitcher map[string]bool) *HarvesterCollector {
+	return &HarvesterCollector{
+		name:         name,
+		pool:         pool,
+		pullSwitcher: pullSwitcher,
+
+		failedToOpenDesc: prometheus.NewDesc(metricName(name, "plots_failed_to_open"), "Number of plots files failed to open.", nil, nil),
+		notFoundDesc:     prometheus.NewDesc(metricName(name, "plots_not_found"), "Number of plots files not found.", nil, nil),
+		plotsDesc:        prometheus.NewDesc(metricName(name, "plots"), "Number of plots currently using.", nil, nil),
+		plotSizeDesc:     prometheus.NewDesc(metricName(name, "plot_size_bytes"), "Distribution of plot file sizes.", nil, nil),
+		plotAgeDesc:      prometheus.NewDesc(metricName(name, "plot_age_seconds"), "Distribution of time since each plot file was last modified.", nil, nil),
+	}
+}
+
+func (c *HarvesterCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *HarvesterCollector) Collect(ch chan<- prometheus.Metric) {
+	if !c.pullSwitcher[IsPlots] {
+		return
+	}
+
+	var plots PlotFiles
+	if err := c.pool.queryAPI("get_plots", "", &plots); err != nil {
+		log.Print(err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.failedToOpenDesc, prometheus.GaugeValue, float64(len(plots.FailedToOpen)))
+	ch <- prometheus.MustNewConstMetric(c.notFoundDesc, prometheus.GaugeValue, float64(len(plots.NotFound)))
+	ch <- prometheus.MustNewConstMetric(c.plotsDesc, prometheus.GaugeValue, float64(len(plots.Plots)))
+
+	now := float64(time.Now().Unix())
+	sizeBuckets := map[float64]uint64{}
+	ageBuckets := map[float64]uint64{}
+	var sizeSum, ageSum float64
+	for _, p := range plots.Plots {
+		size := float64(p.FileSize)
+		age := now - p.TimeModified
+		sizeSum += size
+		ageSum += age
+		for _, b := range plotSizeBuckets {
+			if size <= b {
+				sizeBuckets[b]++
+			}
+		}
+		for _, b := range plotAgeBuckets {
+			if age <= b {
+				ageBuckets[b]++
+			}
+		}
+	}
+	ch <- prometheus.MustNewConstHistogram(c.plotSizeDesc, uint64(len(plots.Plots)), sizeSum, sizeBuckets)
+	ch <- prometheus.MustNewConstHistogram(c.plotAgeDesc, uint64(len(plots.Plots)), ageSum, ageBuckets)
+}
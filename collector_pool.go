@@ -0,0 +1,102 @@
+// Copyright 2021 Kevin Retzke
+//
+// This program is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License for more
+// details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+package main
+
+import (
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolCollector reports per-pool farming state from get_pool_state. Points
+// acknowledged/found are exposed as histograms over the 24h window rather
+// than just their count, so operators can see the distribution of point
+// values rather than a flat count of entries.
+type PoolCollector struct {
+	name         string
+	pool         *ClientPool
+	pullSwitcher map[string]bool
+
+	currentDifficultyDesc  *prometheus.Desc
+	currentPointsDesc      *prometheus.Desc
+	pointsAcknowledgedDesc *prometheus.Desc
+	pointsFoundDesc        *prometheus.Desc
+}
+
+func NewPoolCollector(name string, pool *ClientPool, pullSwitcher map[string]bool) *PoolCollector {
+	labels := []string{"launcher_id", "pool_url"}
+	return &PoolCollector{
+		name:         name,
+		pool:         pool,
+		pullSwitcher: pullSwitcher,
+
+		currentDifficultyDesc: prometheus.NewDesc(metricName(name, "pool_current_difficulty"), "Current difficulty on pool.", labels, nil),
+		currentPointsDesc:     prometheus.NewDesc(metricName(name, "pool_current_points"), "Current points on pool.", labels, nil),
+		pointsAcknowledgedDesc: prometheus.NewDesc(
+			metricName(name, "pool_points_acknowledged_24h"),
+			"Distribution of point values acknowledged by the pool in the last 24h.",
+			labels, nil,
+		),
+		pointsFoundDesc: prometheus.NewDesc(
+			metricName(name, "pool_points_found_24h"),
+			"Distribution of point values found in the last 24h.",
+			labels, nil,
+		),
+	}
+}
+
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	if !c.pullSwitcher[IsPool] {
+		return
+	}
+
+	var pools PoolState
+	if err := c.pool.queryAPI("get_pool_state", "", &pools); err != nil {
+		log.Print(err)
+		return
+	}
+
+	for _, p := range pools.PoolState {
+		labels := []string{p.PoolConfig.LauncherId, p.PoolConfig.PoolURL}
+
+		ch <- prometheus.MustNewConstMetric(c.currentDifficultyDesc, prometheus.GaugeValue, float64(p.CurrentDificulty), labels...)
+		ch <- prometheus.MustNewConstMetric(c.currentPointsDesc, prometheus.GaugeValue, float64(p.CurrentPoints), labels...)
+		ch <- pointsHistogram(c.pointsAcknowledgedDesc, p.PointsAcknowledged24h, labels)
+		ch <- pointsHistogram(c.pointsFoundDesc, p.PointsFound24h, labels)
+	}
+}
+
+// pointsHistogram builds a const histogram over the values of a
+// points_acknowledged_24h/points_found_24h sample array, bucketed on
+// powers of two since individual point values span several orders of
+// magnitude depending on pool difficulty.
+func pointsHistogram(desc *prometheus.Desc, points []PoolPoint, labels []string) prometheus.Metric {
+	buckets := map[float64]uint64{}
+	var sum float64
+	for _, p := range points {
+		v := p.Value()
+		sum += v
+		for _, b := range prometheus.ExponentialBuckets(1, 2, 20) {
+			if v <= b {
+				buckets[b]++
+			}
+		}
+	}
+	return prometheus.MustNewConstHistogram(desc, uint64(len(points)), sum, buckets, labels...)
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 Kevin Retzke
+//
+// This program is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License for more
+// details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestPointsHistogram(t *testing.T) {
+	desc := prometheus.NewDesc("test_points", "help", []string{"l"}, nil)
+	points := []PoolPoint{
+		{0, 1},   // falls in every bucket (smallest value)
+		{0, 3},   // falls in buckets >= 4
+		{0, 100}, // falls in buckets >= 128
+	}
+
+	m := pointsHistogram(desc, points, []string{"v"})
+
+	var out dto.Metric
+	if err := m.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	h := out.GetHistogram()
+
+	if got, want := h.GetSampleCount(), uint64(len(points)); got != want {
+		t.Errorf("sample count = %d, want %d", got, want)
+	}
+	if got, want := h.GetSampleSum(), 1.0+3.0+100.0; got != want {
+		t.Errorf("sample sum = %v, want %v", got, want)
+	}
+
+	counts := map[float64]uint64{}
+	for _, b := range h.Bucket {
+		counts[b.GetUpperBound()] = b.GetCumulativeCount()
+	}
+	if got := counts[1]; got != 1 {
+		t.Errorf("bucket <=1 count = %d, want 1 (only the value 1 fits)", got)
+	}
+	if got := counts[4]; got != 2 {
+		t.Errorf("bucket <=4 count = %d, want 2 (values 1 and 3 fit)", got)
+	}
+	if got := counts[128]; got != 3 {
+		t.Errorf("bucket <=128 count = %d, want 3 (all values fit)", got)
+	}
+}
+
+func TestPointsHistogramEmpty(t *testing.T) {
+	desc := prometheus.NewDesc("test_points", "help", []string{"l"}, nil)
+	m := pointsHistogram(desc, nil, []string{"v"})
+
+	var out dto.Metric
+	if err := m.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	h := out.GetHistogram()
+	if got := h.GetSampleCount(); got != 0 {
+		t.Errorf("sample count = %d, want 0", got)
+	}
+	if got := h.GetSampleSum(); got != 0 {
+		t.Errorf("sample sum = %v, want 0", got)
+	}
+}
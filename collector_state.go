@@ -0,0 +1,131 @@
+// Copyright 2021 Kevin Retzke
+//
+// This program is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License for more
+// details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ChainStateCollector reports sync status and chain metrics from
+// get_blockchain_state, including a summary of the wall-clock interval
+// between consecutive peaks (i.e. block production time).
+type ChainStateCollector struct {
+	name         string
+	pool         *ClientPool
+	pullSwitcher map[string]bool
+
+	syncStatusDesc *prometheus.Desc
+	heightDesc     *prometheus.Desc
+	difficultyDesc *prometheus.Desc
+	spaceDesc      *prometheus.Desc
+	totalItersDesc *prometheus.Desc
+	blockInterval  prometheus.Summary
+
+	mu         sync.Mutex
+	lastPeak   uint32
+	lastPeakAt time.Time
+}
+
+func NewChainStateCollector(name string, pool *ClientPool, pullSwitcher map[string]bool) *ChainStateCollector {
+	return &ChainStateCollector{
+		name:         name,
+		pool:         pool,
+		pullSwitcher: pullSwitcher,
+
+		syncStatusDesc: prometheus.NewDesc(
+			metricName(name, "blockchain_sync_status"),
+			"Sync status, 0=not synced, 1=syncing, 2=synced",
+			nil, nil,
+		),
+		heightDesc: prometheus.NewDesc(
+			metricName(name, "blockchain_height"),
+			"Current height",
+			nil, nil,
+		),
+		difficultyDesc: prometheus.NewDesc(
+			metricName(name, "blockchain_difficulty"),
+			"Current difficulty",
+			nil, nil,
+		),
+		spaceDesc: prometheus.NewDesc(
+			metricName(name, "blockchain_space_bytes"),
+			"Estimated current netspace",
+			nil, nil,
+		),
+		totalItersDesc: prometheus.NewDesc(
+			metricName(name, "blockchain_total_iters"),
+			"Current total iterations",
+			nil, nil,
+		),
+		blockInterval: prometheus.NewSummary(prometheus.SummaryOpts{
+			Name:       metricName(name, "block_interval_seconds"),
+			Help:       "Wall-clock time between consecutive observed peak heights.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		}),
+	}
+}
+
+func (c *ChainStateCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *ChainStateCollector) Collect(ch chan<- prometheus.Metric) {
+	if !c.pullSwitcher[IsState] {
+		return
+	}
+
+	var bs BlockchainState
+	if err := c.pool.queryAPI("get_blockchain_state", "", &bs); err != nil {
+		log.Print(err)
+		return
+	}
+
+	sync := 0.0
+	if bs.BlockchainState.Sync.SyncMode {
+		sync = 1.0
+	} else if bs.BlockchainState.Sync.Synced {
+		sync = 2.0
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.syncStatusDesc, prometheus.GaugeValue, sync)
+	ch <- prometheus.MustNewConstMetric(c.heightDesc, prometheus.GaugeValue, float64(bs.BlockchainState.Peak.Height))
+	ch <- prometheus.MustNewConstMetric(c.difficultyDesc, prometheus.GaugeValue, float64(bs.BlockchainState.Difficulty))
+	ch <- prometheus.MustNewConstMetric(c.spaceDesc, prometheus.GaugeValue, bs.BlockchainState.Space)
+	ch <- prometheus.MustNewConstMetric(c.totalItersDesc, prometheus.GaugeValue, float64(bs.BlockchainState.Peak.TotalIters))
+
+	c.observeBlockInterval(bs.BlockchainState.Peak.Height)
+	c.blockInterval.Collect(ch)
+}
+
+// observeBlockInterval records the time since the last distinct peak height
+// we saw, so the block_interval_seconds summary tracks actual block
+// production time rather than our scrape interval.
+func (c *ChainStateCollector) observeBlockInterval(height uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.lastPeak != 0 && height > c.lastPeak {
+		c.blockInterval.Observe(now.Sub(c.lastPeakAt).Seconds())
+	}
+	if height != c.lastPeak {
+		c.lastPeak = height
+		c.lastPeakAt = now
+	}
+}
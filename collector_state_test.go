@@ -0,0 +1,62 @@
+// Copyright 2021 Kevin Retzke
+//
+// This program is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License for more
+// details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+package main
+
+import (
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func blockIntervalSampleCount(t *testing.T, c *ChainStateCollector) uint64 {
+	t.Helper()
+	var out dto.Metric
+	if err := c.blockInterval.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	return out.GetSummary().GetSampleCount()
+}
+
+// TestObserveBlockInterval verifies block_interval_seconds only records an
+// observation when the peak height advances past its previous high, not on
+// every scrape or on a height that repeats or regresses.
+func TestObserveBlockInterval(t *testing.T) {
+	c := NewChainStateCollector("chia", nil, nil)
+
+	c.observeBlockInterval(100)
+	if got := blockIntervalSampleCount(t, c); got != 0 {
+		t.Fatalf("after first-ever peak: sample count = %d, want 0", got)
+	}
+
+	c.observeBlockInterval(100)
+	if got := blockIntervalSampleCount(t, c); got != 0 {
+		t.Fatalf("after repeated height: sample count = %d, want 0", got)
+	}
+
+	c.observeBlockInterval(101)
+	if got := blockIntervalSampleCount(t, c); got != 1 {
+		t.Fatalf("after new peak: sample count = %d, want 1", got)
+	}
+
+	c.observeBlockInterval(50)
+	if got := blockIntervalSampleCount(t, c); got != 1 {
+		t.Fatalf("after height regression: sample count = %d, want 1", got)
+	}
+
+	c.observeBlockInterval(51)
+	if got := blockIntervalSampleCount(t, c); got != 2 {
+		t.Fatalf("after advancing past the regressed height: sample count = %d, want 2", got)
+	}
+}
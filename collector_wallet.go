@@ -0,0 +1,165 @@
+// Copyright 2021 Kevin Retzke
+//
+// This program is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License for more
+// details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WalletCollector reports balance, sync status and farmed amounts for every
+// wallet known to the wallet RPC.
+type WalletCollector struct {
+	name         string
+	pool         *ClientPool
+	pullSwitcher map[string]bool
+
+	confirmedBalanceDesc   *prometheus.Desc
+	unconfirmedBalanceDesc *prometheus.Desc
+	spendableBalanceDesc   *prometheus.Desc
+	maxSendDesc            *prometheus.Desc
+	pendingChangeDesc      *prometheus.Desc
+	walletSyncStatusDesc   *prometheus.Desc
+	walletHeightDesc       *prometheus.Desc
+	farmedAmountDesc       *prometheus.Desc
+	rewardAmountDesc       *prometheus.Desc
+	feeAmountDesc          *prometheus.Desc
+	lastHeightFarmedDesc   *prometheus.Desc
+	poolRewardAmountDesc   *prometheus.Desc
+}
+
+func NewWalletCollector(name string, pool *ClientPool, pullSwitcher map[string]bool) *WalletCollector {
+	labels := []string{"wallet_id", "wallet_fingerprint"}
+	return &WalletCollector{
+		name:         name,
+		pool:         pool,
+		pullSwitcher: pullSwitcher,
+
+		confirmedBalanceDesc:   prometheus.NewDesc(metricName(name, "wallet_confirmed_balance_mojo"), "Confirmed wallet balance.", labels, nil),
+		unconfirmedBalanceDesc: prometheus.NewDesc(metricName(name, "wallet_unconfirmed_balance_mojo"), "Unconfirmed wallet balance.", labels, nil),
+		spendableBalanceDesc:   prometheus.NewDesc(metricName(name, "wallet_spendable_balance_mojo"), "Spendable wallet balance.", labels, nil),
+		maxSendDesc:            prometheus.NewDesc(metricName(name, "wallet_max_send_mojo"), "Maximum sendable amount.", labels, nil),
+		pendingChangeDesc:      prometheus.NewDesc(metricName(name, "wallet_pending_change_mojo"), "Pending change amount.", labels, nil),
+		walletSyncStatusDesc:   prometheus.NewDesc(metricName(name, "wallet_sync_status"), "Sync status, 0=not synced, 1=syncing, 2=synced", labels, nil),
+		walletHeightDesc:       prometheus.NewDesc(metricName(name, "wallet_height"), "Wallet synced height.", labels, nil),
+		farmedAmountDesc:       prometheus.NewDesc(metricName(name, "wallet_farmed_amount"), "Farmed amount", labels, nil),
+		rewardAmountDesc:       prometheus.NewDesc(metricName(name, "wallet_reward_amount"), "Reward amount", labels, nil),
+		feeAmountDesc:          prometheus.NewDesc(metricName(name, "wallet_fee_amount"), "Fee amount amount", labels, nil),
+		lastHeightFarmedDesc:   prometheus.NewDesc(metricName(name, "wallet_last_height_farmed"), "Last height farmed", labels, nil),
+		poolRewardAmountDesc:   prometheus.NewDesc(metricName(name, "wallet_pool_reward_amount"), "Pool Reward amount", labels, nil),
+	}
+}
+
+func (c *WalletCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *WalletCollector) Collect(ch chan<- prometheus.Metric) {
+	if !c.pullSwitcher[IsWalletBalance] && !c.pullSwitcher[IsWalletSync] && !c.pullSwitcher[IsFarmed] {
+		return
+	}
+
+	var ws Wallets
+	if err := c.pool.queryAPI("get_wallets", "", &ws); err != nil {
+		log.Print(err)
+		return
+	}
+	for _, w := range ws.Wallets {
+		w.StringID = strconv.Itoa(w.ID)
+		w.PublicKey = c.getWalletPublicKey(w)
+		if c.pullSwitcher[IsWalletBalance] {
+			c.collectBalance(ch, w)
+		}
+		if c.pullSwitcher[IsWalletSync] {
+			c.collectSync(ch, w)
+		}
+		if c.pullSwitcher[IsFarmed] {
+			c.collectFarmedAmount(ch, w)
+		}
+	}
+}
+
+// getWalletPublicKey returns the fingerprint of first public key associated
+// with the wallet.
+func (c *WalletCollector) getWalletPublicKey(w Wallet) string {
+	var wpks WalletPublicKeys
+	q := fmt.Sprintf(`{"wallet_id":%d}`, w.ID)
+	if err := c.pool.queryAPI("get_public_keys", q, &wpks); err != nil {
+		log.Print(err)
+		return ""
+	}
+	if len(wpks.PublicKeyFingerprints) < 1 {
+		log.Print("no public key")
+		return ""
+	}
+	if len(wpks.PublicKeyFingerprints) > 1 {
+		log.Print("more than one public key; returning first")
+	}
+	return strconv.Itoa(wpks.PublicKeyFingerprints[0])
+}
+
+func (c *WalletCollector) collectBalance(ch chan<- prometheus.Metric, w Wallet) {
+	var wb WalletBalance
+	q := fmt.Sprintf(`{"wallet_id":%d}`, w.ID)
+	if err := c.pool.queryAPI("get_wallet_balance", q, &wb); err != nil {
+		log.Print(err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.confirmedBalanceDesc, prometheus.GaugeValue, float64(wb.WalletBalance.ConfirmedBalance), w.StringID, w.PublicKey)
+	ch <- prometheus.MustNewConstMetric(c.unconfirmedBalanceDesc, prometheus.GaugeValue, float64(wb.WalletBalance.UnconfirmedBalance), w.StringID, w.PublicKey)
+	ch <- prometheus.MustNewConstMetric(c.spendableBalanceDesc, prometheus.GaugeValue, float64(wb.WalletBalance.SpendableBalance), w.StringID, w.PublicKey)
+	ch <- prometheus.MustNewConstMetric(c.maxSendDesc, prometheus.GaugeValue, float64(wb.WalletBalance.MaxSendAmount), w.StringID, w.PublicKey)
+	ch <- prometheus.MustNewConstMetric(c.pendingChangeDesc, prometheus.GaugeValue, float64(wb.WalletBalance.PendingChange), w.StringID, w.PublicKey)
+}
+
+func (c *WalletCollector) collectSync(ch chan<- prometheus.Metric, w Wallet) {
+	var wss WalletSyncStatus
+	q := fmt.Sprintf(`{"wallet_id":%d}`, w.ID)
+	if err := c.pool.queryAPI("get_sync_status", q, &wss); err != nil {
+		log.Print(err)
+		return
+	}
+	sync := 0.0
+	if wss.Syncing {
+		sync = 1.0
+	} else if wss.Synced {
+		sync = 2.0
+	}
+	ch <- prometheus.MustNewConstMetric(c.walletSyncStatusDesc, prometheus.GaugeValue, sync, w.StringID, w.PublicKey)
+
+	var whi WalletHeightInfo
+	if err := c.pool.queryAPI("get_height_info", q, &whi); err != nil {
+		log.Print(err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.walletHeightDesc, prometheus.GaugeValue, float64(whi.Height), w.StringID, w.PublicKey)
+}
+
+func (c *WalletCollector) collectFarmedAmount(ch chan<- prometheus.Metric, w Wallet) {
+	var farmed FarmedAmount
+	q := fmt.Sprintf(`{"wallet_id":%d}`, w.ID)
+	if err := c.pool.queryAPI("get_farmed_amount", q, &farmed); err != nil {
+		log.Print(err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.farmedAmountDesc, prometheus.GaugeValue, float64(farmed.FarmedAmount), w.StringID, w.PublicKey)
+	ch <- prometheus.MustNewConstMetric(c.rewardAmountDesc, prometheus.GaugeValue, float64(farmed.RewardAmount), w.StringID, w.PublicKey)
+	ch <- prometheus.MustNewConstMetric(c.feeAmountDesc, prometheus.GaugeValue, float64(farmed.FeeAmount), w.StringID, w.PublicKey)
+	ch <- prometheus.MustNewConstMetric(c.lastHeightFarmedDesc, prometheus.GaugeValue, float64(farmed.LastHeightFarmed), w.StringID, w.PublicKey)
+	ch <- prometheus.MustNewConstMetric(c.poolRewardAmountDesc, prometheus.GaugeValue, float64(farmed.PoolRewardAmount), w.StringID, w.PublicKey)
+}
@@ -0,0 +1,333 @@
+// Copyright 2021 Kevin Retzke
+//
+// This program is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License for more
+// details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+)
+
+// coinState is everything ConfigManager keeps alive for a single configured
+// coin. Each coin gets its own *prometheus.Registry so unregistering it on
+// reload drops every series for that coin in one step, rather than having
+// to track and remove individual collectors from a shared registry.
+type coinState struct {
+	coin     Coin
+	client   *http.Client
+	coll     Collector
+	registry *prometheus.Registry
+}
+
+// ConfigManager owns the parsed config.yaml and the set of live coins built
+// from it, and knows how to reload both in place: new coins are
+// constructed, removed coins are torn down, and changed coins are rebuilt
+// and swapped in atomically. This lets operators add a fork or adjust an
+// existing one without restarting the exporter.
+type ConfigManager struct {
+	path string
+
+	// reloadMu serializes Reload calls (SIGHUP, the file watcher and
+	// /-/reload can all trigger one concurrently); it is held across each
+	// reload's network probes, unlike mu below.
+	reloadMu sync.Mutex
+
+	// mu guards only the live coins map, so a scrape never blocks on a
+	// reload's network calls: Reload takes it just long enough to swap in
+	// the new map.
+	mu    sync.RWMutex
+	coins map[string]*coinState
+
+	selfRegistry          *prometheus.Registry
+	lastReloadSuccess     prometheus.Gauge
+	lastReloadSuccessTime prometheus.Gauge
+}
+
+func NewConfigManager(path string) (*ConfigManager, error) {
+	cm := &ConfigManager{
+		path:  path,
+		coins: make(map[string]*coinState),
+
+		selfRegistry: prometheus.NewRegistry(),
+		lastReloadSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "chia_exporter_config_last_reload_success",
+			Help: "Whether the last attempt to reload config.yaml succeeded.",
+		}),
+		lastReloadSuccessTime: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "chia_exporter_config_last_reload_success_timestamp_seconds",
+			Help: "Unix time of the last successful config.yaml reload.",
+		}),
+	}
+	cm.selfRegistry.MustRegister(cm.lastReloadSuccess, cm.lastReloadSuccessTime)
+
+	if err := cm.Reload(); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+// Reload re-reads config.yaml and diffs it against the running set of
+// coins: coins whose config is unchanged are left alone, new or changed
+// coins are (re)built, and coins no longer present are torn down. Building
+// a coin probes its upstream over the network, so that work happens before
+// mu is ever taken for writing: a slow or unreachable upstream only delays
+// this reload, not every concurrent /metrics scrape.
+func (cm *ConfigManager) Reload() error {
+	cm.reloadMu.Lock()
+	defer cm.reloadMu.Unlock()
+
+	cfg, err := cm.load()
+	if err != nil {
+		cm.lastReloadSuccess.Set(0)
+		return err
+	}
+
+	cm.mu.RLock()
+	current := make(map[string]*coinState, len(cm.coins))
+	for name, s := range cm.coins {
+		current[name] = s
+	}
+	cm.mu.RUnlock()
+
+	next := make(map[string]*coinState, len(cfg.Coins))
+	var added, reloaded, removed []string
+	for name, coin := range cfg.Coins {
+		existing := current[name]
+		if existing != nil && reflect.DeepEqual(existing.coin, coin) {
+			next[name] = existing
+			continue
+		}
+
+		state, err := buildCoinState(name, coin)
+		if err != nil {
+			cm.lastReloadSuccess.Set(0)
+			return fmt.Errorf("building coin %s: %w", name, err)
+		}
+		next[name] = state
+		if existing != nil {
+			reloaded = append(reloaded, name)
+		} else {
+			added = append(added, name)
+		}
+	}
+	for name := range current {
+		if _, ok := cfg.Coins[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+
+	cm.mu.Lock()
+	cm.coins = next
+	cm.mu.Unlock()
+
+	for _, name := range added {
+		log.Printf("[%s] coin added", name)
+	}
+	for _, name := range reloaded {
+		closeCoinState(current[name])
+		log.Printf("[%s] config changed, coin reloaded", name)
+	}
+	for _, name := range removed {
+		closeCoinState(current[name])
+		log.Printf("[%s] coin removed", name)
+	}
+
+	cm.lastReloadSuccess.Set(1)
+	cm.lastReloadSuccessTime.SetToCurrentTime()
+	return nil
+}
+
+func (cm *ConfigManager) load() (Config, error) {
+	cfgSrc, err := ioutil.ReadFile(cm.path)
+	if err != nil {
+		return Config{}, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(cfgSrc, &cfg); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// buildCoinState constructs everything needed to scrape one coin: its TLS
+// client, a client pool per role, and a dedicated registry with every
+// subsystem collector registered under a "coin" label.
+func buildCoinState(name string, coin Coin) (*coinState, error) {
+	client, err := newClient(os.ExpandEnv(coin.Cert), os.ExpandEnv(coin.Key))
+	if err != nil {
+		return nil, err
+	}
+
+	mode := ParseSchedulerMode(coin.Scheduler)
+	timeouts, err := parseTimeouts(coin.Timeouts)
+	if err != nil {
+		return nil, err
+	}
+
+	fullNodeURLs, err := urls(coin.Host, coin.FullNodePort)
+	if err != nil {
+		return nil, fmt.Errorf("full-node endpoints: %w", err)
+	}
+	walletURLs, err := urls(coin.Host, coin.WalletPort)
+	if err != nil {
+		return nil, fmt.Errorf("wallet endpoints: %w", err)
+	}
+	farmerURLs, err := urls(coin.Host, coin.FarmerPort)
+	if err != nil {
+		return nil, fmt.Errorf("farmer endpoints: %w", err)
+	}
+	harvesterURLs, err := urls(coin.Host, coin.HarvesterPort)
+	if err != nil {
+		return nil, fmt.Errorf("harvester endpoints: %w", err)
+	}
+
+	metrics := newPoolMetrics(name)
+	coll := Collector{
+		name:         name,
+		fullNode:     NewClientPool(name, "full-node", client, fullNodeURLs, mode, timeouts, metrics),
+		wallet:       NewClientPool(name, "wallet", client, walletURLs, mode, timeouts, metrics),
+		farmer:       NewClientPool(name, "farmer", client, farmerURLs, mode, timeouts, metrics),
+		harvester:    NewClientPool(name, "harvester", client, harvesterURLs, mode, timeouts, metrics),
+		poolMetrics:  metrics,
+		pullSwitcher: coin.PullSwitcher,
+	}
+
+	var info NetworkInfo
+	if err := coll.fullNode.queryAPI("get_network_info", "", &info); err != nil {
+		log.Print(name, err)
+	} else {
+		log.Printf("[%s] Connected to node on %s", name, info.NetworkName)
+	}
+
+	registry := prometheus.NewRegistry()
+	registerCoin(prometheus.WrapRegistererWith(prometheus.Labels{"coin": name}, registry), coll)
+
+	return &coinState{coin: coin, client: client, coll: coll, registry: registry}, nil
+}
+
+// closeCoinState releases the resources a coin held, notably its HTTP
+// transport's idle connections, once it's no longer referenced.
+func closeCoinState(s *coinState) {
+	if t, ok := s.client.Transport.(*http.Transport); ok {
+		t.CloseIdleConnections()
+	}
+}
+
+// Gatherers returns a snapshot of every live coin's registry plus the
+// manager's own reload-status registry, suitable for a single /metrics
+// scrape.
+func (cm *ConfigManager) Gatherers() prometheus.Gatherers {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	gs := make(prometheus.Gatherers, 0, len(cm.coins)+1)
+	gs = append(gs, cm.selfRegistry)
+	for _, s := range cm.coins {
+		gs = append(gs, s.registry)
+	}
+	return gs
+}
+
+// Pools returns every client pool across every live coin, so the scrape
+// handler can hand them all the current request's context.
+func (cm *ConfigManager) Pools() []*ClientPool {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	var pools []*ClientPool
+	for _, s := range cm.coins {
+		pools = append(pools, s.coll.fullNode, s.coll.wallet, s.coll.farmer, s.coll.harvester)
+	}
+	return pools
+}
+
+// ReloadHandler implements the standard Prometheus ecosystem POST /-/reload
+// convention.
+func (cm *ConfigManager) ReloadHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if err := cm.Reload(); err != nil {
+			http.Error(w, fmt.Sprintf("reload failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Watch reloads the config whenever config.yaml changes on disk or the
+// process receives SIGHUP, until ctx is cancelled.
+func (cm *ConfigManager) Watch(ctx context.Context) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("config file watch disabled: %v", err)
+		watcher = nil
+	} else {
+		defer watcher.Close()
+		if err := watcher.Add(filepath.Dir(cm.path)); err != nil {
+			log.Printf("config file watch disabled: %v", err)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+			log.Print("SIGHUP received, reloading config")
+			if err := cm.Reload(); err != nil {
+				log.Printf("reload failed: %v", err)
+			}
+		case event := <-watcherEvents(watcher):
+			if filepath.Clean(event.Name) != filepath.Clean(cm.path) {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			log.Print("config file changed, reloading")
+			if err := cm.Reload(); err != nil {
+				log.Printf("reload failed: %v", err)
+			}
+		}
+	}
+}
+
+// watcherEvents returns w.Events, or a nil channel (which simply never
+// fires in a select) when the watcher couldn't be created.
+func watcherEvents(w *fsnotify.Watcher) <-chan fsnotify.Event {
+	if w == nil {
+		return nil
+	}
+	return w.Events
+}
@@ -15,11 +15,11 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"net"
@@ -27,10 +27,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"gopkg.in/yaml.v2"
 )
@@ -43,16 +41,6 @@ var (
 	Version = "1.0.0"
 )
 
-const (
-	IsConn          = "conn"
-	IsState         = "state"
-	IsWalletSync    = "wallet-sync"
-	IsWalletBalance = "wallet-balance"
-	IsFarmed        = "farmed-amount"
-	IsPlots         = "plots"
-	IsPool          = "pool"
-)
-
 // yaml config struct representation
 type (
 	Config struct {
@@ -60,67 +48,100 @@ type (
 		Coins map[string]Coin `yaml:"coins"`
 	}
 	Coin struct {
-		Cert          string          `yaml:"cert"`
-		Key           string          `yaml:"key"`
-		Host          string          `yaml:"host"`
-		FullNodePort  string          `yaml:"full-node-port"`
-		WalletPort    string          `yaml:"wallet-port"`
-		FarmerPort    string          `yaml:"farmer-port"`
-		HarvesterPort string          `yaml:"harvester-port"`
-		PullSwitcher  map[string]bool `yaml:"pull-switcher"`
-	}
+		Cert          string            `yaml:"cert"`
+		Key           string            `yaml:"key"`
+		Host          Endpoints         `yaml:"host"`
+		FullNodePort  Endpoints         `yaml:"full-node-port"`
+		WalletPort    Endpoints         `yaml:"wallet-port"`
+		FarmerPort    Endpoints         `yaml:"farmer-port"`
+		HarvesterPort Endpoints         `yaml:"harvester-port"`
+		Scheduler     string            `yaml:"scheduler"`
+		Timeouts      map[string]string `yaml:"timeouts"`
+		PullSwitcher  map[string]bool   `yaml:"pull-switcher"`
+	}
+
+	// Endpoints is a list of yaml scalars that also accepts a single bare
+	// scalar, so existing single-host configs keep working unchanged
+	// while HA setups can list several hosts or ports.
+	Endpoints []string
 )
 
-func main() {
-	log.Printf("chia_exporter_nforks version %s", Version)
-	flag.Parse()
+func (e *Endpoints) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var multi []string
+	if err := unmarshal(&multi); err == nil {
+		*e = multi
+		return nil
+	}
+	var single string
+	if err := unmarshal(&single); err != nil {
+		return err
+	}
+	*e = []string{single}
+	return nil
+}
 
-	f, err := os.Open(*config)
-	if err != nil {
-		log.Fatal(err)
+// urls pairs host and port lists into "host:port" endpoint addresses. A
+// single-element list is broadcast against the other, so operators only
+// need to list out whichever of host/port actually varies. It's an error
+// for host and port to both list more than one entry with different
+// lengths, since there's no sensible way to pair e.g. 2 hosts against 3
+// ports.
+func urls(hosts, ports Endpoints) ([]string, error) {
+	if len(hosts) == 0 || len(ports) == 0 {
+		return nil, fmt.Errorf("host and port must each have at least one entry")
 	}
-	defer f.Close()
-	cfgSrc, err := ioutil.ReadAll(f)
-	if err != nil {
-		log.Fatal(err)
+	if len(hosts) > 1 && len(ports) > 1 && len(hosts) != len(ports) {
+		return nil, fmt.Errorf("%d hosts configured against %d ports; lengths must match unless one is a single shared value", len(hosts), len(ports))
 	}
 
-	config := Config{}
-	err = yaml.Unmarshal(cfgSrc, &config)
-	if err != nil {
-		log.Fatal(err)
+	n := len(hosts)
+	if len(ports) > n {
+		n = len(ports)
+	}
+	out := make([]string, n)
+	for i := 0; i < n; i++ {
+		out[i] = fmt.Sprintf("%s:%s", pick(hosts, i), pick(ports, i))
 	}
+	return out, nil
+}
 
-	collectors := CoinsCollector{
-		Collectors: make(map[string]Collector),
+// pick returns list[i], or list[0] if list has a single entry shared
+// across every endpoint.
+func pick(list Endpoints, i int) string {
+	if len(list) == 1 {
+		return list[0]
 	}
+	return list[i]
+}
 
-	for name, coin := range config.Coins {
-		coll := Collector{
-			name:         name,
-			baseURL:      fmt.Sprintf("%s:%s", coin.Host, coin.FullNodePort),
-			walletURL:    fmt.Sprintf("%s:%s", coin.Host, coin.WalletPort),
-			farmerURL:    fmt.Sprintf("%s:%s", coin.Host, coin.FarmerPort),
-			harvesterURL: fmt.Sprintf("%s:%s", coin.Host, coin.HarvesterPort),
-			pullSwitcher: coin.PullSwitcher,
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "capture" {
+		if err := runCapture(os.Args[2:]); err != nil {
+			log.Fatal(err)
 		}
+		return
+	}
 
-		client, err := newClient(os.ExpandEnv(coin.Cert), os.ExpandEnv(coin.Key))
-		if err != nil {
-			log.Fatal(name, err)
-		}
-		var info NetworkInfo
-		if err := queryAPI(client, coll.baseURL, "get_network_info", "", &info); err != nil {
-			log.Print(name, err)
-		} else {
-			log.Printf("[%s] Connected to node at %s on %s", name, coll.baseURL, info.NetworkName)
-		}
+	log.Printf("chia_exporter_nforks version %s", Version)
+	flag.Parse()
 
-		coll.client = client
-		collectors.Collectors[name] = coll
+	cm, err := NewConfigManager(*config)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	prometheus.MustRegister(collectors)
+	watchCtx, cancelWatch := context.WithCancel(context.Background())
+	defer cancelWatch()
+	go cm.Watch(watchCtx)
+
+	cfgSrc, err := ioutil.ReadFile(*config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(cfgSrc, &cfg); err != nil {
+		log.Fatal(err)
+	}
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "chia_exporter_nforks version %s\n", Version)
@@ -128,13 +149,76 @@ func main() {
 		fmt.Fprintf(w, "This program is free software released under the GNU AGPL.\n")
 		fmt.Fprintf(w, "The source code is availabe at https://github.com/gusaul/chia_exporter_nforks\n")
 	})
-	http.Handle("/metrics", promhttp.Handler())
+	http.Handle("/metrics", scrapeHandler(cm, metricsHandler(cm)))
+	http.Handle("/-/reload", cm.ReloadHandler())
 
-	addr := fmt.Sprintf(":%s", config.Port)
+	addr := fmt.Sprintf(":%s", cfg.Port)
 	log.Printf("Listening on %s. Serving metrics on /metrics.", addr)
 	log.Fatal(http.ListenAndServe(addr, nil))
 }
 
+// metricsHandler gathers the current set of coin registries fresh on every
+// request, so a reload that added or removed a coin is reflected on the
+// very next scrape.
+func metricsHandler(cm *ConfigManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		promhttp.HandlerFor(cm.Gatherers(), promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
+
+// parseTimeouts converts the yaml "timeouts" map (RPC endpoint -> duration
+// string, e.g. "get_plots: 30s") into a map keyed the same way ClientPool
+// expects.
+func parseTimeouts(raw map[string]string) (map[string]time.Duration, error) {
+	out := make(map[string]time.Duration, len(raw))
+	for endpoint, s := range raw {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout for %s: %w", endpoint, err)
+		}
+		out[endpoint] = d
+	}
+	return out, nil
+}
+
+// scrapeHandler gives every live pool the scrape's own context before
+// delegating to next, so a slow RPC is cancelled once Prometheus gives up
+// on us rather than running to completion in the background. It honors the
+// standard X-Prometheus-Scrape-Timeout-Seconds header when present. Pools
+// are read fresh from cm on every request so a reload is picked up
+// immediately. Each pool is locked to this scrape for the duration of
+// next.ServeHTTP via beginScrape/endScrape, so a second concurrent scrape
+// of the same pool waits its turn instead of racing on its context.
+func scrapeHandler(cm *ConfigManager, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if s := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); s != "" {
+			if secs, err := strconv.ParseFloat(s, 64); err == nil {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, time.Duration(secs*float64(time.Second)))
+				defer cancel()
+			}
+		}
+
+		pools := cm.Pools()
+		for _, p := range pools {
+			p.beginScrape(ctx)
+		}
+		defer func() {
+			for _, p := range pools {
+				p.endScrape()
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newClient builds the shared *http.Client a coin's pools dial through. It
+// deliberately leaves http.Client.Timeout unset: that deadline would apply
+// on top of and independently of the request context's deadline, silently
+// capping any per-endpoint "timeouts" override below its value. Request
+// timeouts are enforced solely through the context queryAPI is called with
+// (see ClientPool.queryAPI).
 func newClient(cert, key string) (*http.Client, error) {
 	c, err := tls.LoadX509KeyPair(cert, key)
 	if err != nil {
@@ -157,502 +241,26 @@ func newClient(cert, key string) (*http.Client, error) {
 				InsecureSkipVerify: true,
 			},
 		},
-		Timeout: 5 * time.Second,
 	}, nil
 }
 
-func queryAPI(client *http.Client, base, endpoint, query string, result interface{}) error {
+func queryAPI(ctx context.Context, client *http.Client, base, endpoint, query string, result interface{}) error {
 	if query == "" {
 		query = `{"":""}`
 	}
-	b := strings.NewReader(query)
-	r, err := client.Post(base+"/"+endpoint, "application/json", b)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/"+endpoint, strings.NewReader(query))
 	if err != nil {
-		return fmt.Errorf("error calling %s: %w", endpoint, err)
-	}
-	//t := io.TeeReader(r.Body, os.Stdout)
-	t := io.TeeReader(r.Body, ioutil.Discard)
-	if err := json.NewDecoder(t).Decode(result); err != nil {
-		if err != nil {
-			return fmt.Errorf("error decoding %s response: %w", endpoint, err)
-		}
-	}
-	return nil
-}
-
-type CoinsCollector struct {
-	Collectors map[string]Collector
-}
-
-type Collector struct {
-	name         string
-	client       *http.Client
-	baseURL      string
-	walletURL    string
-	farmerURL    string
-	harvesterURL string
-	pullSwitcher map[string]bool
-}
-
-// Describe is implemented with DescribeByCollect.
-func (cc CoinsCollector) Describe(ch chan<- *prometheus.Desc) {
-	prometheus.DescribeByCollect(cc, ch)
-}
-
-// Collect queries Chia and returns metrics on ch.
-func (cc CoinsCollector) Collect(ch chan<- prometheus.Metric) {
-	var wg sync.WaitGroup
-	for _, coll := range cc.Collectors {
-		currColl := coll
-
-		wg.Add(1)
-		go func() {
-			currColl.collectConnections(ch)
-			wg.Done()
-		}()
-
-		wg.Add(1)
-		go func() {
-			currColl.collectBlockchainState(ch)
-			wg.Done()
-		}()
-
-		wg.Add(1)
-		go func() {
-			currColl.collectWallets(ch)
-			wg.Done()
-		}()
-
-		wg.Add(1)
-		go func() {
-			currColl.collectPoolState(ch)
-			wg.Done()
-		}()
-
-		wg.Add(1)
-		go func() {
-			currColl.collectPlots(ch)
-			wg.Done()
-		}()
+		return fmt.Errorf("error building request to %s: %w", endpoint, err)
 	}
-	wg.Wait()
-}
+	req.Header.Set("Content-Type", "application/json")
 
-func (cc Collector) collectConnections(ch chan<- prometheus.Metric) {
-	if !cc.pullSwitcher[IsConn] {
-		return
-	}
-
-	var conns Connections
-	if err := queryAPI(cc.client, cc.baseURL, "get_connections", "", &conns); err != nil {
-		log.Print(err)
-		return
-	}
-	peers := make([]int, NumNodeTypes)
-	for _, p := range conns.Connections {
-		peers[p.Type-1]++
-	}
-	desc := prometheus.NewDesc(
-		fmt.Sprintf("%s_peers_count", cc.name),
-		"Number of peers currently connected.",
-		[]string{"type"}, nil,
-	)
-	for nt, cnt := range peers {
-		ch <- prometheus.MustNewConstMetric(
-			desc,
-			prometheus.GaugeValue,
-			float64(cnt),
-			strconv.Itoa(nt+1),
-		)
-	}
-}
-
-func (cc Collector) collectBlockchainState(ch chan<- prometheus.Metric) {
-	if !cc.pullSwitcher[IsState] {
-		return
-	}
-
-	var bs BlockchainState
-	if err := queryAPI(cc.client, cc.baseURL, "get_blockchain_state", "", &bs); err != nil {
-		log.Print(err)
-		return
-	}
-	sync := 0.0
-	if bs.BlockchainState.Sync.SyncMode {
-		sync = 1.0
-	} else if bs.BlockchainState.Sync.Synced {
-		sync = 2.0
-	}
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(
-			fmt.Sprintf("%s_blockchain_sync_status", cc.name),
-			"Sync status, 0=not synced, 1=syncing, 2=synced",
-			nil, nil,
-		),
-		prometheus.GaugeValue,
-		sync,
-	)
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(
-			fmt.Sprintf("%s_blockchain_height", cc.name),
-			"Current height",
-			nil, nil,
-		),
-		prometheus.GaugeValue,
-		float64(bs.BlockchainState.Peak.Height),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(
-			fmt.Sprintf("%s_blockchain_difficulty", cc.name),
-			"Current difficulty",
-			nil, nil,
-		),
-		prometheus.GaugeValue,
-		float64(bs.BlockchainState.Difficulty),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(
-			fmt.Sprintf("%s_blockchain_space_bytes", cc.name),
-			"Estimated current netspace",
-			nil, nil,
-		),
-		prometheus.GaugeValue,
-		bs.BlockchainState.Space,
-	)
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(
-			fmt.Sprintf("%s_blockchain_total_iters", cc.name),
-			"Current total iterations",
-			nil, nil,
-		),
-		prometheus.GaugeValue,
-		float64(bs.BlockchainState.Peak.TotalIters),
-	)
-}
-
-func (cc Collector) collectWallets(ch chan<- prometheus.Metric) {
-	if !cc.pullSwitcher[IsWalletBalance] && !cc.pullSwitcher[IsWalletSync] && !cc.pullSwitcher[IsFarmed] {
-		return
-	}
-
-	var ws Wallets
-	if err := queryAPI(cc.client, cc.walletURL, "get_wallets", "", &ws); err != nil {
-		log.Print(err)
-		return
-	}
-	for _, w := range ws.Wallets {
-		w.StringID = strconv.Itoa(w.ID)
-		w.PublicKey = cc.getWalletPublicKey(w)
-		if cc.pullSwitcher[IsWalletBalance] {
-			cc.collectWalletBalance(ch, w)
-		}
-		if cc.pullSwitcher[IsWalletSync] {
-			cc.collectWalletSync(ch, w)
-		}
-		if cc.pullSwitcher[IsFarmed] {
-			cc.collectFarmedAmount(ch, w)
-		}
-	}
-}
-
-// getWalletPublicKey returns the fingerprint of first public key associated
-// with the wallet.
-func (cc Collector) getWalletPublicKey(w Wallet) string {
-	var wpks WalletPublicKeys
-	q := fmt.Sprintf(`{"wallet_id":%d}`, w.ID)
-	if err := queryAPI(cc.client, cc.walletURL, "get_public_keys", q, &wpks); err != nil {
-		log.Print(err)
-		return ""
-	}
-	if len(wpks.PublicKeyFingerprints) < 1 {
-		log.Print("no public key")
-		return ""
-	}
-	if len(wpks.PublicKeyFingerprints) > 1 {
-		log.Print("more than one public key; returning first")
-	}
-	return strconv.Itoa(wpks.PublicKeyFingerprints[0])
-}
-
-var (
-	confirmedBalanceDesc = func(name string) *prometheus.Desc {
-		return prometheus.NewDesc(
-			fmt.Sprintf("%s_wallet_confirmed_balance_mojo", name),
-			"Confirmed wallet balance.",
-			[]string{"wallet_id", "wallet_fingerprint"}, nil,
-		)
-	}
-	unconfirmedBalanceDesc = func(name string) *prometheus.Desc {
-		return prometheus.NewDesc(
-			fmt.Sprintf("%s_wallet_unconfirmed_balance_mojo", name),
-			"Unconfirmed wallet balance.",
-			[]string{"wallet_id", "wallet_fingerprint"}, nil,
-		)
-	}
-	spendableBalanceDesc = func(name string) *prometheus.Desc {
-		return prometheus.NewDesc(
-			fmt.Sprintf("%s_wallet_spendable_balance_mojo", name),
-			"Spendable wallet balance.",
-			[]string{"wallet_id", "wallet_fingerprint"}, nil,
-		)
-	}
-	maxSendDesc = func(name string) *prometheus.Desc {
-		return prometheus.NewDesc(
-			fmt.Sprintf("%s_wallet_max_send_mojo", name),
-			"Maximum sendable amount.",
-			[]string{"wallet_id", "wallet_fingerprint"}, nil,
-		)
-	}
-	pendingChangeDesc = func(name string) *prometheus.Desc {
-		return prometheus.NewDesc(
-			fmt.Sprintf("%s_wallet_pending_change_mojo", name),
-			"Pending change amount.",
-			[]string{"wallet_id", "wallet_fingerprint"}, nil,
-		)
-	}
-)
-
-func (cc Collector) collectWalletBalance(ch chan<- prometheus.Metric, w Wallet) {
-	var wb WalletBalance
-	q := fmt.Sprintf(`{"wallet_id":%d}`, w.ID)
-	if err := queryAPI(cc.client, cc.walletURL, "get_wallet_balance", q, &wb); err != nil {
-		log.Print(err)
-		return
-	}
-	ch <- prometheus.MustNewConstMetric(
-		confirmedBalanceDesc(cc.name),
-		prometheus.GaugeValue,
-		float64(wb.WalletBalance.ConfirmedBalance),
-		w.StringID, w.PublicKey,
-	)
-	ch <- prometheus.MustNewConstMetric(
-		unconfirmedBalanceDesc(cc.name),
-		prometheus.GaugeValue,
-		float64(wb.WalletBalance.UnconfirmedBalance),
-		w.StringID, w.PublicKey,
-	)
-	ch <- prometheus.MustNewConstMetric(
-		spendableBalanceDesc(cc.name),
-		prometheus.GaugeValue,
-		float64(wb.WalletBalance.SpendableBalance),
-		w.StringID, w.PublicKey,
-	)
-	ch <- prometheus.MustNewConstMetric(
-		maxSendDesc(cc.name),
-		prometheus.GaugeValue,
-		float64(wb.WalletBalance.MaxSendAmount),
-		w.StringID, w.PublicKey,
-	)
-	ch <- prometheus.MustNewConstMetric(
-		pendingChangeDesc(cc.name),
-		prometheus.GaugeValue,
-		float64(wb.WalletBalance.PendingChange),
-		w.StringID, w.PublicKey,
-	)
-}
-
-var (
-	walletSyncStatusDesc = func(name string) *prometheus.Desc {
-		return prometheus.NewDesc(
-			fmt.Sprintf("%s_wallet_sync_status", name),
-			"Sync status, 0=not synced, 1=syncing, 2=synced",
-			[]string{"wallet_id", "wallet_fingerprint"}, nil,
-		)
-	}
-	walletHeightDesc = func(name string) *prometheus.Desc {
-		return prometheus.NewDesc(
-			fmt.Sprintf("%s_wallet_height", name),
-			"Wallet synced height.",
-			[]string{"wallet_id", "wallet_fingerprint"}, nil,
-		)
-	}
-)
-
-func (cc Collector) collectWalletSync(ch chan<- prometheus.Metric, w Wallet) {
-	var wss WalletSyncStatus
-	q := fmt.Sprintf(`{"wallet_id":%d}`, w.ID)
-	if err := queryAPI(cc.client, cc.walletURL, "get_sync_status", q, &wss); err != nil {
-		log.Print(err)
-		return
-	}
-	sync := 0.0
-	if wss.Syncing {
-		sync = 1.0
-	} else if wss.Synced {
-		sync = 2.0
-	}
-	ch <- prometheus.MustNewConstMetric(
-		walletSyncStatusDesc(cc.name),
-		prometheus.GaugeValue,
-		sync,
-		w.StringID, w.PublicKey,
-	)
-
-	var whi WalletHeightInfo
-	if err := queryAPI(cc.client, cc.walletURL, "get_height_info", q, &whi); err != nil {
-		log.Print(err)
-		return
-	}
-	ch <- prometheus.MustNewConstMetric(
-		walletHeightDesc(cc.name),
-		prometheus.GaugeValue,
-		float64(whi.Height),
-		w.StringID, w.PublicKey,
-	)
-}
-
-func (cc Collector) collectPoolState(ch chan<- prometheus.Metric) {
-	if !cc.pullSwitcher[IsPool] {
-		return
-	}
-
-	var pools PoolState
-	if err := queryAPI(cc.client, cc.farmerURL, "get_pool_state", "", &pools); err != nil {
-		log.Print(err)
-		return
-	}
-	for _, p := range pools.PoolState {
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				fmt.Sprintf("%s_pool_current_difficulty", cc.name),
-				"Current difficulty on pool.",
-				[]string{"launcher_id", "pool_url"}, nil,
-			),
-			prometheus.GaugeValue,
-			float64(p.CurrentDificulty),
-			p.PoolConfig.LauncherId,
-			p.PoolConfig.PoolURL,
-		)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				fmt.Sprintf("%s_pool_current_points", cc.name),
-				"Current points on pool.",
-				[]string{"launcher_id", "pool_url"}, nil,
-			),
-			prometheus.GaugeValue,
-			float64(p.CurrentPoints),
-			p.PoolConfig.LauncherId,
-			p.PoolConfig.PoolURL,
-		)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				fmt.Sprintf("%s_pool_points_acknowledged_24h", cc.name),
-				"Points acknowledged last 24h on pool.",
-				[]string{"launcher_id", "pool_url"}, nil,
-			),
-			prometheus.GaugeValue,
-			float64(len(p.PointsAcknowledged24h)),
-			p.PoolConfig.LauncherId,
-			p.PoolConfig.PoolURL,
-		)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				fmt.Sprintf("%s_pool_points_found_24h", cc.name),
-				"Points found last 24h on pool.",
-				[]string{"launcher_id", "pool_url"}, nil,
-			),
-			prometheus.GaugeValue,
-			float64(len(p.PointsFound24h)),
-			p.PoolConfig.LauncherId,
-			p.PoolConfig.PoolURL,
-		)
-	}
-}
-
-func (cc Collector) collectPlots(ch chan<- prometheus.Metric) {
-	if !cc.pullSwitcher[IsPlots] {
-		return
+	r, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling %s: %w", endpoint, err)
 	}
-
-	var plots PlotFiles
-	if err := queryAPI(cc.client, cc.harvesterURL, "get_plots", "", &plots); err != nil {
-		log.Print(err)
-		return
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(result); err != nil {
+		return fmt.Errorf("error decoding %s response: %w", endpoint, err)
 	}
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(
-			fmt.Sprintf("%s_plots_failed_to_open", cc.name),
-			"Number of plots files failed to open.",
-			nil, nil,
-		),
-		prometheus.GaugeValue,
-		float64(len(plots.FailedToOpen)),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(
-			fmt.Sprintf("%s_plots_not_found", cc.name),
-			"Number of plots files not found.",
-			nil, nil,
-		),
-		prometheus.GaugeValue,
-		float64(len(plots.NotFound)),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(
-			fmt.Sprintf("%s_plots", cc.name),
-			"Number of plots currently using.",
-			nil, nil,
-		),
-		prometheus.GaugeValue,
-		float64(len(plots.Plots)),
-	)
-}
-
-func (cc Collector) collectFarmedAmount(ch chan<- prometheus.Metric, w Wallet) {
-	var farmed FarmedAmount
-	q := fmt.Sprintf(`{"wallet_id":%d}`, w.ID)
-	if err := queryAPI(cc.client, cc.walletURL, "get_farmed_amount", q, &farmed); err != nil {
-		log.Print(err)
-		return
-	}
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(
-			fmt.Sprintf("%s_wallet_farmed_amount", cc.name),
-			"Farmed amount",
-			[]string{"wallet_id", "wallet_fingerprint"}, nil,
-		),
-		prometheus.GaugeValue,
-		float64(farmed.FarmedAmount),
-		w.StringID, w.PublicKey,
-	)
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(
-			fmt.Sprintf("%s_wallet_reward_amount", cc.name),
-			"Reward amount",
-			[]string{"wallet_id", "wallet_fingerprint"}, nil,
-		),
-		prometheus.GaugeValue,
-		float64(farmed.RewardAmount),
-		w.StringID, w.PublicKey,
-	)
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(
-			fmt.Sprintf("%s_wallet_fee_amount", cc.name),
-			"Fee amount amount",
-			[]string{"wallet_id", "wallet_fingerprint"}, nil,
-		),
-		prometheus.GaugeValue,
-		float64(farmed.FeeAmount),
-		w.StringID, w.PublicKey,
-	)
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(
-			fmt.Sprintf("%s_wallet_last_height_farmed", cc.name),
-			"Last height farmed",
-			[]string{"wallet_id", "wallet_fingerprint"}, nil,
-		),
-		prometheus.GaugeValue,
-		float64(farmed.LastHeightFarmed),
-		w.StringID, w.PublicKey,
-	)
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(
-			fmt.Sprintf("%s_wallet_pool_reward_amount", cc.name),
-			"Pool Reward amount",
-			[]string{"wallet_id", "wallet_fingerprint"}, nil,
-		),
-		prometheus.GaugeValue,
-		float64(farmed.PoolRewardAmount),
-		w.StringID, w.PublicKey,
-	)
+	return nil
 }
@@ -0,0 +1,60 @@
+// Copyright 2021 Kevin Retzke
+//
+// This program is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License for more
+// details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestURLs(t *testing.T) {
+	tests := []struct {
+		name  string
+		hosts Endpoints
+		ports Endpoints
+		want  []string
+	}{
+		{"single host and port", Endpoints{"h"}, Endpoints{"1"}, []string{"h:1"}},
+		{"single host broadcast across ports", Endpoints{"h"}, Endpoints{"1", "2"}, []string{"h:1", "h:2"}},
+		{"single port broadcast across hosts", Endpoints{"h1", "h2"}, Endpoints{"1"}, []string{"h1:1", "h2:1"}},
+		{"matching multi-entry lists", Endpoints{"h1", "h2"}, Endpoints{"1", "2"}, []string{"h1:1", "h2:2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := urls(tt.hosts, tt.ports)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("urls(%v, %v) = %v, want %v", tt.hosts, tt.ports, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestURLsMismatchedLengths(t *testing.T) {
+	if _, err := urls(Endpoints{"h1", "h2"}, Endpoints{"1", "2", "3"}); err == nil {
+		t.Error("expected an error for 2 hosts against 3 ports, got nil")
+	}
+}
+
+func TestURLsEmpty(t *testing.T) {
+	if _, err := urls(nil, Endpoints{"1"}); err == nil {
+		t.Error("expected an error for an empty host list, got nil")
+	}
+	if _, err := urls(Endpoints{"h"}, nil); err == nil {
+		t.Error("expected an error for an empty port list, got nil")
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 Kevin Retzke
+//
+// This program is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License for more
+// details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+package main
+
+// NumNodeTypes is the number of distinct chia NodeType values
+// (full_node, harvester, farmer, timelord, introducer, wallet).
+const NumNodeTypes = 6
+
+type NetworkInfo struct {
+	NetworkName   string `json:"network_name"`
+	NetworkPrefix string `json:"network_prefix"`
+}
+
+type Connections struct {
+	Connections []Connection `json:"connections"`
+}
+
+type Connection struct {
+	Type         int    `json:"type"`
+	PeerHost     string `json:"peer_host"`
+	BytesRead    int64  `json:"bytes_read"`
+	BytesWritten int64  `json:"bytes_written"`
+}
+
+type BlockchainState struct {
+	BlockchainState struct {
+		Sync struct {
+			SyncMode bool `json:"sync_mode"`
+			Synced   bool `json:"synced"`
+		} `json:"sync"`
+		Peak struct {
+			Height     uint32 `json:"height"`
+			TotalIters uint64 `json:"total_iters"`
+		} `json:"peak"`
+		Difficulty uint64  `json:"difficulty"`
+		Space      float64 `json:"space"`
+	} `json:"blockchain_state"`
+}
+
+type Wallets struct {
+	Wallets []Wallet `json:"wallets"`
+}
+
+type Wallet struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+
+	// populated by the collector before use, not part of the RPC response.
+	StringID  string `json:"-"`
+	PublicKey string `json:"-"`
+}
+
+type WalletPublicKeys struct {
+	PublicKeyFingerprints []int `json:"public_key_fingerprints"`
+}
+
+type WalletBalance struct {
+	WalletBalance struct {
+		ConfirmedBalance   uint64 `json:"confirmed_wallet_balance"`
+		UnconfirmedBalance uint64 `json:"unconfirmed_wallet_balance"`
+		SpendableBalance   uint64 `json:"spendable_balance"`
+		MaxSendAmount      uint64 `json:"max_send_amount"`
+		PendingChange      uint64 `json:"pending_change"`
+	} `json:"wallet_balance"`
+}
+
+type WalletSyncStatus struct {
+	Syncing bool `json:"syncing"`
+	Synced  bool `json:"synced"`
+}
+
+type WalletHeightInfo struct {
+	Height uint32 `json:"height"`
+}
+
+type PoolState struct {
+	PoolState []PoolStateEntry `json:"pool_state"`
+}
+
+// PoolPoint is a single [timestamp, value] sample as returned in the
+// points_acknowledged_24h / points_found_24h arrays.
+type PoolPoint [2]float64
+
+func (p PoolPoint) Timestamp() float64 { return p[0] }
+func (p PoolPoint) Value() float64     { return p[1] }
+
+type PoolStateEntry struct {
+	CurrentDificulty      uint64      `json:"current_difficulty"`
+	CurrentPoints         uint64      `json:"current_points"`
+	PointsAcknowledged24h []PoolPoint `json:"points_acknowledged_24h"`
+	PointsFound24h        []PoolPoint `json:"points_found_24h"`
+	PoolConfig            struct {
+		LauncherId string `json:"launcher_id"`
+		PoolURL    string `json:"pool_url"`
+	} `json:"pool_config"`
+}
+
+type PlotFiles struct {
+	FailedToOpen []string   `json:"failed_to_open_filenames"`
+	NotFound     []string   `json:"not_found_filenames"`
+	Plots        []PlotInfo `json:"plots"`
+}
+
+type PlotInfo struct {
+	Filename     string  `json:"filename"`
+	FileSize     int64   `json:"file_size"`
+	TimeModified float64 `json:"time_modified"`
+}
+
+type FarmedAmount struct {
+	FarmedAmount     uint64 `json:"farmed_amount"`
+	RewardAmount     uint64 `json:"reward_amount"`
+	FeeAmount        uint64 `json:"fee_amount"`
+	LastHeightFarmed uint32 `json:"last_height_farmed"`
+	PoolRewardAmount uint64 `json:"pool_reward_amount"`
+}